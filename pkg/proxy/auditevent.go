@@ -0,0 +1,187 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	auditapi "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/audit/policy"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/transport"
+	"k8s.io/klog"
+
+	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/context"
+)
+
+// authPath records which authentication/authorization path a request took,
+// for inclusion in the emitted audit event.
+type authPath string
+
+const (
+	authPathNoImpersonation authPath = "NoImpersonation"
+	authPathTokenReview     authPath = "TokenReview"
+	authPathImpersonation   authPath = "Impersonation"
+)
+
+// buildAuditPolicyChecker loads an audit policy file, if one is configured,
+// returning nil if no policy file is set so that callers fall back to
+// auditing every request at metadata level.
+func buildAuditPolicyChecker(policyFile string) (policy.Checker, error) {
+	if policyFile == "" {
+		return nil, nil
+	}
+
+	rules, err := policy.LoadPolicyFromFile(policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit policy file %q: %s", policyFile, err)
+	}
+
+	return policy.NewChecker(rules.Rules), nil
+}
+
+// levelForRequest determines the audit level a request should be logged
+// at, consulting the configured audit policy if one is set.
+func (p *Proxy) levelForRequest(req *http.Request, userInfo user.Info) auditapi.Level {
+	if p.auditPolicyChecker == nil {
+		return auditapi.LevelMetadata
+	}
+
+	attrs := authorizer.AttributesRecord{
+		User:            userInfo,
+		Verb:            req.Method,
+		Path:            req.URL.Path,
+		ResourceRequest: false,
+	}
+
+	if info, ok := request.RequestInfoFrom(req.Context()); ok {
+		attrs.Verb = info.Verb
+		attrs.Namespace = info.Namespace
+		attrs.Resource = info.Resource
+		attrs.Subresource = info.Subresource
+		attrs.APIGroup = info.APIGroup
+		attrs.APIVersion = info.APIVersion
+		attrs.Name = info.Name
+		attrs.ResourceRequest = info.IsResourceRequest
+	}
+
+	level, _ := p.auditPolicyChecker.LevelFor(attrs)
+	return level
+}
+
+// emitAuditEvent builds and emits an audit event describing an
+// authentication/impersonation decision made for req, returning the
+// AuditID it generated so that a caller handling a WebSocket upgrade can
+// pass it to emitWebSocketCloseAuditEvent to correlate the two events.
+func (p *Proxy) emitAuditEvent(req *http.Request, path authPath, inbound user.Info, impersonated *transport.ImpersonationConfig, sarAllowed *bool, status int) types.UID {
+	level := p.levelForRequest(req, inbound)
+	if level == auditapi.LevelNone {
+		return ""
+	}
+
+	_, remoteAddr := context.RemoteAddr(req)
+
+	// Audit-ID is generated here rather than trusted from the client: the
+	// real API server generates it server-side too, since a client-
+	// supplied value can't be relied on to be present or unique.
+	auditID := uuid.NewUUID()
+
+	now := metav1.NewMicroTime(time.Now())
+	ev := &auditapi.Event{
+		Level:                    level,
+		AuditID:                  auditID,
+		Stage:                    auditapi.StageResponseComplete,
+		RequestURI:               req.URL.RequestURI(),
+		Verb:                     req.Method,
+		User:                     userInfoToAuditUser(inbound),
+		SourceIPs:                []string{remoteAddr},
+		UserAgent:                req.UserAgent(),
+		RequestReceivedTimestamp: now,
+		StageTimestamp:           now,
+		Annotations: map[string]string{
+			"authentication.kube-oidc-proxy.jetstack.io/path": string(path),
+		},
+	}
+
+	if impersonated != nil {
+		ev.ImpersonatedUser = &auditapi.UserInfo{Username: impersonated.UserName, Groups: impersonated.Groups}
+	}
+
+	if sarAllowed != nil {
+		ev.Annotations["authorization.kube-oidc-proxy.jetstack.io/subject-access-review-allowed"] = fmt.Sprint(*sarAllowed)
+	}
+
+	if status != 0 {
+		ev.ResponseStatus = &metav1.Status{Code: int32(status)}
+	}
+
+	if p.auditor == nil {
+		return auditID
+	}
+
+	if ok := p.auditor.ProcessEvents(ev); !ok {
+		klog.V(4).Infof("audit: failed to process event for request to %s", req.URL.Path)
+	}
+
+	return auditID
+}
+
+// emitWebSocketCloseAuditEvent emits an audit event recording that a
+// WebSocket connection, opened under auditID by a prior call to
+// emitAuditEvent, has closed, including the number of bytes read from and
+// written to the client.
+func (p *Proxy) emitWebSocketCloseAuditEvent(req *http.Request, inbound user.Info, auditID types.UID, bytesRead, bytesWritten int64) {
+	level := p.levelForRequest(req, inbound)
+	if level == auditapi.LevelNone || p.auditor == nil {
+		return
+	}
+
+	_, remoteAddr := context.RemoteAddr(req)
+	now := metav1.NewMicroTime(time.Now())
+
+	ev := &auditapi.Event{
+		Level:                    level,
+		AuditID:                  auditID,
+		Stage:                    auditapi.StageResponseComplete,
+		RequestURI:               req.URL.RequestURI(),
+		Verb:                     req.Method,
+		User:                     userInfoToAuditUser(inbound),
+		SourceIPs:                []string{remoteAddr},
+		UserAgent:                req.UserAgent(),
+		RequestReceivedTimestamp: now,
+		StageTimestamp:           now,
+		Annotations: map[string]string{
+			"authentication.kube-oidc-proxy.jetstack.io/path":     string(authPathImpersonation),
+			"websocket.kube-oidc-proxy.jetstack.io/bytes-read":    fmt.Sprint(bytesRead),
+			"websocket.kube-oidc-proxy.jetstack.io/bytes-written": fmt.Sprint(bytesWritten),
+		},
+	}
+
+	if ok := p.auditor.ProcessEvents(ev); !ok {
+		klog.V(4).Infof("audit: failed to process WebSocket close event for request to %s", req.URL.Path)
+	}
+}
+
+func userInfoToAuditUser(u user.Info) auditapi.UserInfo {
+	if u == nil {
+		return auditapi.UserInfo{}
+	}
+
+	extra := make(map[string]auditapi.ExtraValue, len(u.GetExtra()))
+	for k, v := range u.GetExtra() {
+		extra[k] = auditapi.ExtraValue(v)
+	}
+
+	return auditapi.UserInfo{
+		Username: u.GetName(),
+		UID:      u.GetUID(),
+		Groups:   u.GetGroups(),
+		Extra:    extra,
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	auditapi "k8s.io/apiserver/pkg/apis/audit"
+)
+
+func TestLevelForRequestWithoutPolicyDefaultsToMetadata(t *testing.T) {
+	p := &Proxy{}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+
+	if got, want := p.levelForRequest(req, nil), auditapi.LevelMetadata; got != want {
+		t.Errorf("levelForRequest() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+)
+
+// defaultCAReloadGracePeriod is how long a CA bundle provider is allowed to
+// fail to read a valid bundle before the /readyz check returned by
+// CertReloadHealthCheck starts failing.
+const defaultCAReloadGracePeriod = 5 * time.Minute
+
+// caBundleProvider is satisfied by both reloadableCABundle and nilCABundle,
+// so callers can treat a configured and an unconfigured CA file uniformly.
+// It is deliberately narrower than dynamiccertificates.CAContentProvider
+// (no Name/VerifyOptions) since its only consumers are oidc.Options, which
+// wants just CurrentCABundleContent, and the proxy's own reload/health
+// wiring.
+type caBundleProvider interface {
+	CurrentCABundleContent() []byte
+	AddListener(listener dynamiccertificates.Listener)
+	Run(stopCh <-chan struct{})
+	healthCheck(gracePeriod time.Duration) error
+}
+
+// nilCABundle is a no-op caBundleProvider used when no CA file is
+// configured for a given purpose, so the corresponding peer (e.g. the OIDC
+// issuer) is verified against the system root CAs instead, matching the
+// behaviour of the proxy before dynamic reload was introduced.
+type nilCABundle struct{}
+
+func (nilCABundle) CurrentCABundleContent() []byte             { return nil }
+func (nilCABundle) AddListener(_ dynamiccertificates.Listener) {}
+func (nilCABundle) Run(_ <-chan struct{})                      {}
+func (nilCABundle) healthCheck(_ time.Duration) error          { return nil }
+
+// notifierFunc adapts a plain function to dynamiccertificates.Listener.
+type notifierFunc func()
+
+func (f notifierFunc) Enqueue() {
+	f()
+}
+
+// reloadableCABundle wraps a dynamiccertificates.DynamicFileCAContent,
+// tracking the last time it successfully read a valid CA bundle so that
+// prolonged read failures (e.g. the file being rotated out from under us)
+// can be surfaced via a health check rather than failing silently.
+type reloadableCABundle struct {
+	provider *dynamiccertificates.DynamicFileCAContent
+
+	mu           sync.RWMutex
+	lastGoodRead time.Time
+	lastErr      error
+}
+
+// newCABundle watches file for purpose, or returns a nilCABundle when file
+// is empty: dynamiccertificates.NewDynamicCAContentFromFile refuses to
+// watch an empty filename, but an empty CA file is a normal configuration
+// (e.g. a public OIDC issuer such as Google or Okta, verified against the
+// system root CAs) and must not fail startup.
+func newCABundle(purpose, file string) (caBundleProvider, error) {
+	if file == "" {
+		return nilCABundle{}, nil
+	}
+
+	return newReloadableCABundle(purpose, file)
+}
+
+// newReloadableCABundle creates a reloadableCABundle watching file, doing an
+// initial synchronous read before returning.
+func newReloadableCABundle(purpose, file string) (*reloadableCABundle, error) {
+	provider, err := dynamiccertificates.NewDynamicCAContentFromFile(purpose, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up watcher for %s CA file %q: %s", purpose, file, err)
+	}
+
+	b := &reloadableCABundle{provider: provider}
+	b.reload()
+	provider.AddListener(b)
+
+	return b, nil
+}
+
+// Enqueue implements dynamiccertificates.Listener and is called by the
+// underlying provider whenever the watched file changes on disk.
+func (b *reloadableCABundle) Enqueue() {
+	b.reload()
+}
+
+func (b *reloadableCABundle) reload() {
+	err := b.provider.RunOnce()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastErr = err
+	if err == nil {
+		b.lastGoodRead = time.Now()
+	}
+}
+
+// CurrentCABundleContent implements dynamiccertificates.CAContentProvider.
+func (b *reloadableCABundle) CurrentCABundleContent() []byte {
+	return b.provider.CurrentCABundleContent()
+}
+
+// AddListener implements dynamiccertificates.Notifier, proxying through to
+// the underlying provider so other components (e.g. the OIDC authenticator)
+// can be re-initialised when the CA rotates.
+func (b *reloadableCABundle) AddListener(listener dynamiccertificates.Listener) {
+	b.provider.AddListener(listener)
+}
+
+// Run starts the filesystem watch that keeps the bundle up to date until
+// stopCh is closed.
+func (b *reloadableCABundle) Run(stopCh <-chan struct{}) {
+	go b.provider.Run(1, stopCh)
+}
+
+// healthCheck returns an error if the bundle has been unable to read a
+// valid CA for longer than gracePeriod.
+func (b *reloadableCABundle) healthCheck(gracePeriod time.Duration) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.lastErr == nil {
+		return nil
+	}
+
+	if time.Since(b.lastGoodRead) > gracePeriod {
+		return fmt.Errorf("%s: no valid CA bundle read in over %s: %s",
+			b.provider.Name(), gracePeriod, b.lastErr)
+	}
+
+	return nil
+}
+
+// reloadHealthChecker is satisfied by both caBundleProvider and
+// servingCertProvider, letting certReloadHealthCheck check either kind of
+// watched credential uniformly.
+type reloadHealthChecker interface {
+	healthCheck(gracePeriod time.Duration) error
+}
+
+// certReloadHealthCheck implements a healthz.HealthChecker-shaped check
+// (Name/Check) that operators can register against the proxy's /readyz so
+// that a prolonged failure to reload any watched CA bundle or serving
+// certificate marks the pod not-ready rather than silently serving with
+// stale trust roots or an expired certificate.
+type certReloadHealthCheck struct {
+	checkers    []reloadHealthChecker
+	gracePeriod time.Duration
+}
+
+func (c *certReloadHealthCheck) Name() string {
+	return "cert-reload"
+}
+
+func (c *certReloadHealthCheck) Check(_ *http.Request) error {
+	for _, checker := range c.checkers {
+		if checker == nil {
+			continue
+		}
+		if err := checker.healthCheck(c.gracePeriod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CertReloadHealthCheck returns a health check that fails once any of the
+// proxy's dynamically reloaded CA bundles or its serving certificate has
+// been unable to read a valid bundle for longer than the configured grace
+// period. Register it against the server's /readyz.
+func (p *Proxy) CertReloadHealthCheck() interface {
+	Name() string
+	Check(req *http.Request) error
+} {
+	gracePeriod := p.config.CAReloadGracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = defaultCAReloadGracePeriod
+	}
+
+	return &certReloadHealthCheck{
+		checkers:    []reloadHealthChecker{p.issuerCABundle, p.restConfigCABundle, p.servingCertBundle},
+		gracePeriod: gracePeriod,
+	}
+}
+
+// servingCertProvider mirrors caBundleProvider for the proxy's own serving
+// certificate/key pair, so it can be watched and health-checked the same
+// way as the issuer and API server CA bundles above.
+type servingCertProvider interface {
+	dynamiccertificates.CertKeyContentProvider
+	AddListener(listener dynamiccertificates.Listener)
+	Run(stopCh <-chan struct{})
+	healthCheck(gracePeriod time.Duration) error
+}
+
+// reloadableServingCert wraps a dynamiccertificates.DynamicCertKeyPairContent,
+// tracking the last time it successfully read a valid certificate/key pair
+// so that prolonged read failures (e.g. cert-manager rotating the files out
+// from under us mid-read) can be surfaced via a health check rather than
+// failing silently.
+type reloadableServingCert struct {
+	provider *dynamiccertificates.DynamicCertKeyPairContent
+
+	mu           sync.RWMutex
+	lastGoodRead time.Time
+	lastErr      error
+}
+
+// newServingCertProvider watches certFile/keyFile, doing an initial
+// synchronous read before returning.
+func newServingCertProvider(certFile, keyFile string) (*reloadableServingCert, error) {
+	provider, err := dynamiccertificates.NewDynamicServingContent("serving-cert", certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up watcher for serving cert/key (%q, %q): %s", certFile, keyFile, err)
+	}
+
+	c := &reloadableServingCert{provider: provider}
+	c.reload()
+	provider.AddListener(c)
+
+	return c, nil
+}
+
+// Enqueue implements dynamiccertificates.Listener and is called by the
+// underlying provider whenever the watched cert or key file changes on
+// disk.
+func (c *reloadableServingCert) Enqueue() {
+	c.reload()
+}
+
+func (c *reloadableServingCert) reload() {
+	err := c.provider.RunOnce()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastErr = err
+	if err == nil {
+		c.lastGoodRead = time.Now()
+	}
+}
+
+// CurrentCertKeyContent implements dynamiccertificates.CertKeyContentProvider.
+func (c *reloadableServingCert) CurrentCertKeyContent() ([]byte, []byte) {
+	return c.provider.CurrentCertKeyContent()
+}
+
+// Name implements dynamiccertificates.CertKeyContentProvider.
+func (c *reloadableServingCert) Name() string {
+	return c.provider.Name()
+}
+
+// AddListener implements dynamiccertificates.Notifier, proxying through to
+// the underlying provider so other components can be re-initialised when
+// the serving certificate rotates.
+func (c *reloadableServingCert) AddListener(listener dynamiccertificates.Listener) {
+	c.provider.AddListener(listener)
+}
+
+// Run starts the filesystem watch that keeps the certificate/key pair up
+// to date until stopCh is closed.
+func (c *reloadableServingCert) Run(stopCh <-chan struct{}) {
+	go c.provider.Run(1, stopCh)
+}
+
+// healthCheck returns an error if the certificate/key pair has been unable
+// to read a valid pair for longer than gracePeriod.
+func (c *reloadableServingCert) healthCheck(gracePeriod time.Duration) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.lastErr == nil {
+		return nil
+	}
+
+	if time.Since(c.lastGoodRead) > gracePeriod {
+		return fmt.Errorf("%s: no valid serving certificate read in over %s: %s",
+			c.provider.Name(), gracePeriod, c.lastErr)
+	}
+
+	return nil
+}
+
+// readyzPath is the endpoint operators point a Kubernetes readinessProbe
+// at.
+const readyzPath = "/readyz"
+
+// withReadyzHandler intercepts GET requests to readyzPath and responds
+// according to checker's result, so a health check such as the one
+// returned by CertReloadHealthCheck actually gates traffic instead of
+// being defined but never consulted. All other requests are passed
+// through to handler unchanged.
+func (p *Proxy) withReadyzHandler(handler http.Handler, checker interface {
+	Name() string
+	Check(req *http.Request) error
+}) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || req.URL.Path != readyzPath {
+			handler.ServeHTTP(rw, req)
+			return
+		}
+
+		if err := checker.Check(req); err != nil {
+			http.Error(rw, fmt.Sprintf("%s failed: %s", checker.Name(), err), http.StatusServiceUnavailable)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
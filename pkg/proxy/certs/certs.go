@@ -0,0 +1,274 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+
+// Package certs implements a small in-memory certificate authority used to
+// issue short-lived client certificates to callers that have already
+// authenticated to the proxy via an OIDC bearer token. This allows
+// subsequent requests to present the certificate over mTLS instead of
+// re-running the OIDC verification path on every request.
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultCertDuration is the lifetime given to issued client
+	// certificates when NewSigner is given a non-positive certDuration.
+	DefaultCertDuration = 10 * time.Minute
+
+	// DefaultCARotationInterval is how often the signing CA is rotated
+	// by default.
+	DefaultCARotationInterval = 24 * time.Hour
+
+	caKeyBits = 2048
+)
+
+// Signer is an in-memory CA that issues short-lived client certificates
+// encoding a user's name and groups, and rotates its own signing key on a
+// fixed interval.
+type Signer struct {
+	mu sync.RWMutex
+
+	caCert    *x509.Certificate
+	caCertDER []byte
+	caKey     *rsa.PrivateKey
+
+	// retired holds CAs superseded by a rotation that may still have live
+	// client certificates outstanding, alongside the time after which any
+	// such certificate is guaranteed to have expired and the CA can be
+	// forgotten.
+	retired []retiredCA
+
+	certDuration     time.Duration
+	rotationInterval time.Duration
+
+	listeners []dynamiccertificates.Listener
+}
+
+type retiredCA struct {
+	cert     *x509.Certificate
+	expireAt time.Time
+}
+
+// NewSigner creates a Signer and generates its first signing CA. The CA is
+// rotated every rotationInterval once Run is called, and every certificate
+// issued by Sign is valid for certDuration.
+func NewSigner(certDuration, rotationInterval time.Duration) (*Signer, error) {
+	if certDuration <= 0 {
+		certDuration = DefaultCertDuration
+	}
+	if rotationInterval <= 0 {
+		rotationInterval = DefaultCARotationInterval
+	}
+
+	s := &Signer{
+		certDuration:     certDuration,
+		rotationInterval: rotationInterval,
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Run rotates the signing CA every rotation interval until stopCh is closed.
+func (s *Signer) Run(stopCh <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(s.rotationInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.rotate(); err != nil {
+					klog.Errorf("certs: failed to rotate signing CA: %s", err)
+					continue
+				}
+				klog.V(2).Infof("certs: rotated client certificate signing CA")
+				s.notifyListeners()
+
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// AddListener implements dynamiccertificates.Notifier, registering a
+// listener that is notified whenever the signing CA is rotated, so that
+// callers trusting the CA bundle (e.g. the serving TLS config) can refresh
+// it.
+func (s *Signer) AddListener(listener dynamiccertificates.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *Signer) notifyListeners() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, listener := range s.listeners {
+		listener.Enqueue()
+	}
+}
+
+// Name implements dynamiccertificates.CAContentProvider.
+func (s *Signer) Name() string {
+	return "kube-oidc-proxy-client-cert-ca"
+}
+
+// VerifyOptions implements dynamiccertificates.CAContentProvider, returning
+// the x509.VerifyOptions clients should be checked against, built from the
+// current and recently retired signing CAs.
+func (s *Signer) VerifyOptions() (x509.VerifyOptions, bool) {
+	return x509.VerifyOptions{
+		Roots:     s.CertPool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, true
+}
+
+func (s *Signer) rotate() error {
+	key, err := rsa.GenerateKey(rand.Reader, caKeyBits)
+	if err != nil {
+		return fmt.Errorf("failed to generate CA key: %s", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate CA serial: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "kube-oidc-proxy-client-ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(s.rotationInterval * 2),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to self-sign CA certificate: %s", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse generated CA certificate: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Retain the CA we're replacing until any certificate it may have
+	// signed is guaranteed to have expired, so CertPool can still verify
+	// certs issued just before this rotation.
+	if s.caCert != nil {
+		s.retired = append(s.retired, retiredCA{
+			cert:     s.caCert,
+			expireAt: time.Now().Add(s.certDuration),
+		})
+	}
+	s.retired = pruneRetiredCAs(s.retired)
+
+	s.caCert = cert
+	s.caCertDER = der
+	s.caKey = key
+
+	return nil
+}
+
+// pruneRetiredCAs drops retired CAs whose issued certificates can no longer
+// possibly be valid.
+func pruneRetiredCAs(retired []retiredCA) []retiredCA {
+	now := time.Now()
+	live := retired[:0]
+	for _, r := range retired {
+		if now.Before(r.expireAt) {
+			live = append(live, r)
+		}
+	}
+	return live
+}
+
+// CurrentCABundleContent returns the PEM encoded certificate of the
+// currently active signing CA, for clients to add to their trust roots.
+func (s *Signer) CurrentCABundleContent() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.caCertDER})
+}
+
+// Sign issues a new short-lived client certificate and private key whose
+// CommonName and Organization fields encode username and groups
+// respectively. The returned cert and key are PEM encoded.
+func (s *Signer) Sign(username string, groups []string) (certPEM, keyPEM []byte, err error) {
+	s.mu.RLock()
+	caCert := s.caCert
+	caKey := s.caKey
+	s.mu.RUnlock()
+
+	key, err := rsa.GenerateKey(rand.Reader, caKeyBits)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate client key: %s", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate client serial: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   username,
+			Organization: groups,
+		},
+		NotBefore:   time.Now().Add(-time.Minute),
+		NotAfter:    time.Now().Add(s.certDuration),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to sign client certificate: %s", err)
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	return certPEM, keyPEM, nil
+}
+
+// CertPool returns an x509.CertPool containing the currently active
+// signing CA plus any recently retired CAs whose issued certificates may
+// still be valid, suitable for verifying client certificates presented
+// back to the proxy across a rotation.
+func (s *Signer) CertPool() *x509.CertPool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(s.caCert)
+	for _, r := range s.retired {
+		pool.AddCert(r.cert)
+	}
+
+	return pool
+}
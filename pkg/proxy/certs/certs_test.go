@@ -0,0 +1,105 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package certs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestSignerSignEncodesUsernameAndGroups(t *testing.T) {
+	s, err := NewSigner(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error creating signer: %s", err)
+	}
+
+	certPEM, keyPEM, err := s.Sign("jane.doe", []string{"system:masters", "devs"})
+	if err != nil {
+		t.Fatalf("unexpected error signing certificate: %s", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("failed to decode returned certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse returned certificate: %s", err)
+	}
+
+	if cert.Subject.CommonName != "jane.doe" {
+		t.Errorf("got CommonName %q, want %q", cert.Subject.CommonName, "jane.doe")
+	}
+	if got, want := cert.Subject.Organization, []string{"system:masters", "devs"}; !stringsEqual(got, want) {
+		t.Errorf("got Organization %v, want %v", got, want)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		t.Fatalf("failed to decode returned key PEM")
+	}
+
+	verifyOpts, ok := s.VerifyOptions()
+	if !ok {
+		t.Fatalf("VerifyOptions returned ok=false")
+	}
+	if _, err := cert.Verify(verifyOpts); err != nil {
+		t.Errorf("issued certificate did not verify against the signer's CA: %s", err)
+	}
+}
+
+func TestSignerRotateRetainsRetiredCAForOutstandingCerts(t *testing.T) {
+	s, err := NewSigner(time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error creating signer: %s", err)
+	}
+
+	certPEM, _, err := s.Sign("jane.doe", nil)
+	if err != nil {
+		t.Fatalf("unexpected error signing certificate: %s", err)
+	}
+
+	if err := s.rotate(); err != nil {
+		t.Fatalf("unexpected error rotating CA: %s", err)
+	}
+
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+
+	verifyOpts, _ := s.VerifyOptions()
+	if _, err := cert.Verify(verifyOpts); err != nil {
+		t.Errorf("certificate issued before rotation should still verify against the retired CA: %s", err)
+	}
+}
+
+func TestPruneRetiredCAsDropsExpiredEntries(t *testing.T) {
+	now := time.Now()
+	retired := []retiredCA{
+		{expireAt: now.Add(-time.Minute)}, // expired, should be dropped
+		{expireAt: now.Add(time.Hour)},    // still live, should be kept
+	}
+
+	pruned := pruneRetiredCAs(retired)
+	if len(pruned) != 1 {
+		t.Fatalf("got %d retired CAs after pruning, want 1", len(pruned))
+	}
+	if !pruned[0].expireAt.Equal(retired[1].expireAt) {
+		t.Errorf("pruneRetiredCAs kept the wrong entry")
+	}
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilterRequestHeaders(t *testing.T) {
+	tests := map[string]struct {
+		allowed []string
+		headers map[string]string
+		want    []string
+	}{
+		"default allow-list keeps Authorization and the defaults, stripping client Impersonate-* headers": {
+			headers: map[string]string{
+				"Authorization":    "Bearer token",
+				"Impersonate-User": "jane.doe",
+				"Accept":           "application/json",
+				"X-Evil-Header":    "smuggled",
+			},
+			want: []string{"Authorization", "Accept"},
+		},
+		"configured allow-list replaces the defaults": {
+			allowed: []string{"X-Custom-Header"},
+			headers: map[string]string{
+				"X-Custom-Header": "keep-me",
+				"Accept":          "application/json",
+			},
+			want: []string{"X-Custom-Header"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &Proxy{config: &Config{AllowedRequestHeaders: test.allowed}}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			p.filterRequestHeaders(req)
+
+			for _, name := range test.want {
+				if req.Header.Get(name) == "" {
+					t.Errorf("expected header %q to survive filtering, got headers %v", name, req.Header)
+				}
+			}
+			if got, want := len(req.Header), len(test.want); got != want {
+				t.Errorf("got %d headers after filtering, want %d: %v", got, want, req.Header)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httputil"
@@ -14,10 +13,13 @@ import (
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apiserver/pkg/audit/policy"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/server"
 	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/transport"
 	"k8s.io/klog"
@@ -25,6 +27,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/jetstack/kube-oidc-proxy/cmd/app/options"
 	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/audit"
+	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/certs"
 	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/context"
 	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/hooks"
 	"github.com/jetstack/kube-oidc-proxy/pkg/proxy/logging"
@@ -52,6 +55,62 @@ type Config struct {
 
 	ExtraUserHeaders                map[string][]string
 	ExtraUserHeadersClientIPEnabled bool
+
+	// IssueClientCerts enables the tokencredentialrequests endpoint,
+	// allowing callers to exchange an OIDC bearer token for a short-lived
+	// client certificate that can be used to authenticate directly over
+	// mTLS on subsequent requests.
+	IssueClientCerts bool
+
+	// TLSProfile selects the TLS versions, cipher suites and curves used
+	// both when serving requests and when talking to the upstream API
+	// server. Defaults to TLSProfileDefault.
+	TLSProfile TLSProfile
+
+	// AllowedRequestHeaders is the set of header names, in addition to
+	// Authorization and the Impersonate-* headers the proxy itself sets,
+	// that are forwarded to the API server. Any other header sent by the
+	// client is stripped before the request is forwarded. Defaults to
+	// defaultAllowedRequestHeaders.
+	AllowedRequestHeaders []string
+
+	// CAReloadGracePeriod is how long the issuer CA, API server CA or
+	// serving certificate providers are allowed to fail to read a valid
+	// bundle before the health check registered via
+	// Proxy.CertReloadHealthCheck starts failing. Defaults to
+	// defaultCAReloadGracePeriod.
+	CAReloadGracePeriod time.Duration
+
+	// ServingCertFile and ServingKeyFile locate the proxy's own serving
+	// certificate/key pair on disk. When both are set, New watches them
+	// the same way as the issuer and API server CAs, so a
+	// certificate rotated by e.g. cert-manager is picked up without
+	// restarting the pod; when unset, ssinfo.Cert (set up by the caller)
+	// is used unmodified.
+	ServingCertFile string
+	ServingKeyFile  string
+
+	// AuditPolicyFile, if set, is the path to an audit policy file (in the
+	// same format as the Kubernetes API server's --audit-policy-file)
+	// selecting which requests are audited and at what level. When unset
+	// every request is audited at metadata level.
+	AuditPolicyFile string
+}
+
+// defaultAllowedRequestHeaders is used when Config.AllowedRequestHeaders is
+// left unset.
+var defaultAllowedRequestHeaders = []string{
+	"Accept",
+	"Accept-Encoding",
+	"User-Agent",
+	"Connection",
+	"Upgrade",
+	"Content-Type",
+	"Sec-WebSocket-Key",
+	"Sec-WebSocket-Version",
+	"Sec-WebSocket-Protocol",
+	"Sec-WebSocket-Extensions",
+	"X-Stream-Protocol-Version",
 }
 
 type errorHandlerFn func(http.ResponseWriter, *http.Request, error)
@@ -63,22 +122,24 @@ type Proxy struct {
 	subjectAccessReviewer *subjectaccessreview.SubjectAccessReview
 	secureServingInfo     *server.SecureServingInfo
 	auditor               *audit.Audit
+	certSigner            *certs.Signer
 
 	restConfig            *rest.Config
 	clientTransport       http.RoundTripper
 	noAuthClientTransport http.RoundTripper
 
+	issuerCABundle     caBundleProvider
+	restConfigCABundle caBundleProvider
+	servingCertBundle  servingCertProvider
+	tokenAutherSwapper *swappableTokenAuthenticator
+	auditPolicyChecker policy.Checker
+
 	config *Config
 
 	hooks       *hooks.Hooks
 	handleError errorHandlerFn
 }
 
-// implement oidc.CAContentProvider to load
-// the ca file from the options
-type CAFromFile struct {
-	CAFile string
-}
 type errorResponderWrapper struct {
 	errorHandlerFn
 }
@@ -87,45 +148,117 @@ func (e errorResponderWrapper) Error(w http.ResponseWriter, r *http.Request, err
 	e.errorHandlerFn(w, r, err)
 }
 
-func (caFromFile CAFromFile) CurrentCABundleContent() []byte {
-	res, _ := ioutil.ReadFile(caFromFile.CAFile)
-	return res
-}
-
 func New(restConfig *rest.Config,
 	oidcOptions *options.OIDCAuthenticationOptions,
 	auditOptions *options.AuditOptions,
-	tokenReviewer *tokenreview.TokenReview,
-	subjectAccessReviewer *subjectaccessreview.SubjectAccessReview,
+	tokenReviewRestConfig *rest.Config,
+	subjectAccessReviewRestConfig *rest.Config,
 	ssinfo *server.SecureServingInfo,
 	config *Config) (*Proxy, error) {
 
-	// load the CA from the file listed in the options
-	caFromFile := CAFromFile{
-		CAFile: oidcOptions.CAFile,
+	// watch the issuer CA file on disk so it can be rotated (e.g. by
+	// cert-manager) without restarting the pod; an empty CAFile is a valid
+	// configuration (e.g. a public OIDC issuer) and verifies against the
+	// system root CAs, same as before dynamic reload was introduced.
+	issuerCABundle, err := newCABundle("oidc-issuer-ca", oidcOptions.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	buildTokenAuther := func() (authenticator.Token, error) {
+		return oidc.New(oidc.Options{
+			CAContentProvider:    issuerCABundle,
+			ClientID:             oidcOptions.ClientID,
+			GroupsClaim:          oidcOptions.GroupsClaim,
+			GroupsPrefix:         oidcOptions.GroupsPrefix,
+			IssuerURL:            oidcOptions.IssuerURL,
+			RequiredClaims:       oidcOptions.RequiredClaims,
+			SupportedSigningAlgs: oidcOptions.SigningAlgs,
+			UsernameClaim:        oidcOptions.UsernameClaim,
+			UsernamePrefix:       oidcOptions.UsernamePrefix,
+		})
 	}
 
 	// generate tokenAuther from oidc config
-	tokenAuther, err := oidc.New(oidc.Options{
-		CAContentProvider:    caFromFile,
-		ClientID:             oidcOptions.ClientID,
-		GroupsClaim:          oidcOptions.GroupsClaim,
-		GroupsPrefix:         oidcOptions.GroupsPrefix,
-		IssuerURL:            oidcOptions.IssuerURL,
-		RequiredClaims:       oidcOptions.RequiredClaims,
-		SupportedSigningAlgs: oidcOptions.SigningAlgs,
-		UsernameClaim:        oidcOptions.UsernameClaim,
-		UsernamePrefix:       oidcOptions.UsernamePrefix,
-	})
+	initialTokenAuther, err := buildTokenAuther()
 	if err != nil {
 		return nil, err
 	}
+	tokenAutherSwapper := newSwappableTokenAuthenticator(initialTokenAuther)
+
+	// rebuild the OIDC authenticator whenever the issuer CA rotates, so the
+	// new bundle is picked up without needing to restart the pod
+	issuerCABundle.AddListener(notifierFunc(func() {
+		newTokenAuther, err := buildTokenAuther()
+		if err != nil {
+			klog.Errorf("failed to reload OIDC authenticator after issuer CA change: %s", err)
+			return
+		}
+		tokenAutherSwapper.Set(newTokenAuther)
+	}))
+
+	// watch the CA used to talk to the API server so it can be rotated
+	// without restarting the pod
+	var restConfigCABundle caBundleProvider
+	if restConfig.CAFile != "" {
+		restConfigCABundle, err = newCABundle("api-server-ca", restConfig.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// watch the proxy's own serving certificate/key pair so it can be
+	// rotated without restarting the pod, same as the issuer and API
+	// server CAs above
+	var servingCertBundle servingCertProvider
+	if config.ServingCertFile != "" && config.ServingKeyFile != "" {
+		servingCertBundle, err = newServingCertProvider(config.ServingCertFile, config.ServingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		ssinfo.Cert = servingCertBundle
+	}
 
 	auditor, err := audit.New(auditOptions, config.ExternalAddress, ssinfo)
 	if err != nil {
 		return nil, err
 	}
 
+	auditPolicyChecker, err := buildAuditPolicyChecker(config.AuditPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var certSigner *certs.Signer
+	if config.IssueClientCerts {
+		certSigner, err = certs.NewSigner(certs.DefaultCertDuration, certs.DefaultCARotationInterval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialise client certificate signer: %s", err)
+		}
+	}
+
+	// Build the TokenReview and SubjectAccessReview clients here, rather
+	// than taking them pre-built as parameters, so their transports get
+	// the same TLS profile and BearerTokenFile rotation as the proxy's
+	// own upstream transport (see roundTripperForRestConfig) instead of
+	// silently serving stale credentials after a projected service
+	// account token rotates.
+	var tokenReviewer *tokenreview.TokenReview
+	if tokenReviewRestConfig != nil {
+		tokenReviewer, err = newTokenReviewer(tokenReviewRestConfig, config.TLSProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialise TokenReview client: %s", err)
+		}
+	}
+
+	var subjectAccessReviewer *subjectaccessreview.SubjectAccessReview
+	if subjectAccessReviewRestConfig != nil {
+		subjectAccessReviewer, err = newSubjectAccessReviewer(subjectAccessReviewRestConfig, config.TLSProfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialise SubjectAccessReview client: %s", err)
+		}
+	}
+
 	return &Proxy{
 		restConfig:            restConfig,
 		hooks:                 hooks.New(),
@@ -133,9 +266,15 @@ func New(restConfig *rest.Config,
 		subjectAccessReviewer: subjectAccessReviewer,
 		secureServingInfo:     ssinfo,
 		config:                config,
-		oidcRequestAuther:     bearertoken.New(tokenAuther),
-		tokenAuther:           tokenAuther,
+		oidcRequestAuther:     bearertoken.New(tokenAutherSwapper),
+		tokenAuther:           tokenAutherSwapper,
 		auditor:               auditor,
+		certSigner:            certSigner,
+		issuerCABundle:        issuerCABundle,
+		restConfigCABundle:    restConfigCABundle,
+		servingCertBundle:     servingCertBundle,
+		tokenAutherSwapper:    tokenAutherSwapper,
+		auditPolicyChecker:    auditPolicyChecker,
 	}, nil
 }
 
@@ -145,24 +284,50 @@ func (p *Proxy) Run(stopCh <-chan struct{}) (<-chan struct{}, <-chan struct{}, e
 	if err != nil {
 		return nil, nil, err
 	}
-	p.clientTransport = clientRT
+	clientRTSwapper := newSwappableRoundTripper(clientRT)
+	p.clientTransport = clientRTSwapper
+
+	noAuthRestConfig := &rest.Config{
+		APIPath: p.restConfig.APIPath,
+		Host:    p.restConfig.Host,
+		Timeout: p.restConfig.Timeout,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAFile: p.restConfig.CAFile,
+			CAData: p.restConfig.CAData,
+		},
+	}
 
+	var noAuthRTSwapper *swappableRoundTripper
 	// No auth round tripper for no impersonation
 	if p.config.DisableImpersonation || p.config.TokenReview {
-		noAuthClientRT, err := p.roundTripperForRestConfig(&rest.Config{
-			APIPath: p.restConfig.APIPath,
-			Host:    p.restConfig.Host,
-			Timeout: p.restConfig.Timeout,
-			TLSClientConfig: rest.TLSClientConfig{
-				CAFile: p.restConfig.CAFile,
-				CAData: p.restConfig.CAData,
-			},
-		})
+		noAuthClientRT, err := p.roundTripperForRestConfig(noAuthRestConfig)
 		if err != nil {
 			return nil, nil, err
 		}
 
-		p.noAuthClientTransport = noAuthClientRT
+		noAuthRTSwapper = newSwappableRoundTripper(noAuthClientRT)
+		p.noAuthClientTransport = noAuthRTSwapper
+	}
+
+	// rebuild the transports to the API server whenever the CA used to
+	// verify it rotates on disk, so the change is picked up without
+	// restarting the pod
+	if p.restConfigCABundle != nil {
+		p.restConfigCABundle.AddListener(notifierFunc(func() {
+			if newClientRT, err := p.roundTripperForRestConfig(p.restConfig); err != nil {
+				klog.Errorf("failed to reload API server client transport after CA change: %s", err)
+			} else {
+				clientRTSwapper.Set(newClientRT)
+			}
+
+			if noAuthRTSwapper != nil {
+				if newNoAuthRT, err := p.roundTripperForRestConfig(noAuthRestConfig); err != nil {
+					klog.Errorf("failed to reload no-auth API server client transport after CA change: %s", err)
+				} else {
+					noAuthRTSwapper.Set(newNoAuthRT)
+				}
+			}
+		}))
 	}
 
 	// get API server url
@@ -182,7 +347,13 @@ func (p *Proxy) Run(stopCh <-chan struct{}) (<-chan struct{}, <-chan struct{}, e
 	// Set up WebSocket proxy handler
 	wsProxyHandler := proxy.NewUpgradeAwareHandler(url, p.clientTransport, true, false, errorResponderWrapper{p.handleError})
 
-	waitCh, listenerStoppedCh, err := p.serve(proxyHandler, wsProxyHandler, stopCh)
+	// Set up a WebSocket proxy handler for client-cert authenticated
+	// requests, whose transport impersonates the user encoded in the
+	// verified certificate rather than forwarding with the proxy's own
+	// identity (see certAuthRoundTripper).
+	certAuthWSHandler := proxy.NewUpgradeAwareHandler(url, certAuthRoundTripper{p}, true, false, errorResponderWrapper{p.handleError})
+
+	waitCh, listenerStoppedCh, err := p.serve(proxyHandler, wsProxyHandler, certAuthWSHandler, stopCh)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -190,18 +361,64 @@ func (p *Proxy) Run(stopCh <-chan struct{}) (<-chan struct{}, <-chan struct{}, e
 	return waitCh, listenerStoppedCh, nil
 }
 
-func (p *Proxy) serve(handler http.Handler, wsHandler http.Handler, stopCh <-chan struct{}) (<-chan struct{}, <-chan struct{}, error) {
+func (p *Proxy) serve(handler http.Handler, wsHandler http.Handler, certAuthWSHandler http.Handler, stopCh <-chan struct{}) (<-chan struct{}, <-chan struct{}, error) {
+	rawHandler := handler
+
 	// Setup proxy handlers
 	handler = p.withHandlers(handler)
 
 	// Add WebSocket handler
 	handler = p.withWebSocketHandler(handler, wsHandler)
 
+	// Add the client certificate issuance endpoint, if enabled
+	handler = p.withTokenCredentialRequestHandler(handler)
+
+	// Requests authenticated via a client certificate issued by certSigner
+	// bypass the OIDC bearer-token gate entirely; this must wrap the whole
+	// chain above so it runs before withHandlers rejects them for having
+	// no bearer token. certAuthWSHandler impersonates the certificate's
+	// user itself (see certAuthRoundTripper), so WebSocket upgrades get
+	// the same header filtering, impersonation and audit coverage as the
+	// non-WebSocket rawHandler path does via RoundTrip.
+	handler = p.withClientCertAuthHandler(handler, rawHandler, certAuthWSHandler)
+
+	// Serve /readyz ahead of the auth/impersonation chain above, so
+	// CertReloadHealthCheck actually gates the pod's readiness instead of
+	// being defined but never consulted.
+	handler = p.withReadyzHandler(handler, p.CertReloadHealthCheck())
+
+	// Request (and, via RoundTrip, verify) client certificates issued by
+	// our own signing CA over mTLS, in addition to the normal OIDC
+	// bearer-token path.
+	if p.certSigner != nil {
+		p.secureServingInfo.ClientCA = p.certSigner
+	}
+
 	// Run auditor
 	if err := p.auditor.Run(stopCh); err != nil {
 		return nil, nil, err
 	}
 
+	// Run the client certificate signer, rotating its CA on a fixed interval
+	if p.certSigner != nil {
+		p.certSigner.Run(stopCh)
+	}
+
+	// Watch the issuer CA, API server CA and serving cert/key files for
+	// changes on disk
+	p.issuerCABundle.Run(stopCh)
+	if p.restConfigCABundle != nil {
+		p.restConfigCABundle.Run(stopCh)
+	}
+	if p.servingCertBundle != nil {
+		p.servingCertBundle.Run(stopCh)
+	}
+
+	// restrict the TLS versions and cipher suites offered to clients
+	// according to the configured profile
+	p.secureServingInfo.MinTLSVersion = tlsProfileMinVersion(p.config.TLSProfile)
+	p.secureServingInfo.CipherSuites = tlsProfileCipherSuites(p.config.TLSProfile)
+
 	// securely serve using serving config
 	waitCh, listenerStoppedCh, err := p.secureServingInfo.Serve(handler, time.Second*60, stopCh)
 	if err != nil {
@@ -233,34 +450,112 @@ func (p *Proxy) withWebSocketHandler(handler http.Handler, wsHandler http.Handle
 			// Set authorization header
 			req.Header.Set("Authorization", string(decodedAuthData))
 
+			// Strip any header that isn't explicitly allowed before forwarding.
+			p.filterRequestHeaders(req)
+
 			// Get the impersonation headers from the context.
 			impersonationConf := context.ImpersonationConfig(req)
 			log.Printf("ImpersonationConfig: %v", impersonationConf.ImpersonationConfig)
 			fmt.Printf("[%s] Handling WS Request, Header: %s\n", time.Now().Format(timestampLayout), req.Header)
-			wsHandler.ServeHTTP(rw, req)
+
+			var inboundUser user.Info
+			if impersonationConf != nil && impersonationConf.InboundUser != nil {
+				inboundUser = *impersonationConf.InboundUser
+			}
+
+			p.serveWebSocketWithAudit(rw, req, wsHandler, authPathImpersonation, inboundUser, nil, nil)
 		} else {
 			handler.ServeHTTP(rw, req)
 		}
 	})
 }
 
+// serveWebSocketWithAudit serves a WebSocket upgrade through wsHandler so
+// that, like a non-WebSocket request, it is visible end-to-end in the
+// audit log: it emits path's audit event before serving (the hijacked
+// connection has no other natural point to audit at), counts bytes
+// transferred over the life of the connection, and emits a matching close
+// event once it ends. Used for both the OIDC-impersonated and the
+// client-cert-impersonated WebSocket paths so neither is invisible to
+// auditing the way a plain Transport.RoundTrip would be.
+func (p *Proxy) serveWebSocketWithAudit(rw http.ResponseWriter, req *http.Request, wsHandler http.Handler, path authPath, inbound user.Info, impersonated *transport.ImpersonationConfig, sarAllowed *bool) {
+	auditID := p.emitAuditEvent(req, path, inbound, impersonated, sarAllowed, 0)
+
+	counting := &byteCountingResponseWriter{ResponseWriter: rw}
+	wsHandler.ServeHTTP(counting, req)
+
+	read, written := counting.counts()
+	p.emitWebSocketCloseAuditEvent(req, inbound, auditID, read, written)
+}
+
+// filterRequestHeaders strips every header from req that is not
+// Authorization and not on the configured allow-list, preventing clients
+// from smuggling headers such as spoofed X-Forwarded-* or tracing headers
+// through to the API server. Impersonate-* headers sent by the client are
+// stripped too; the proxy sets its own after filtering when it
+// impersonates.
+func (p *Proxy) filterRequestHeaders(req *http.Request) {
+	allowed := p.config.AllowedRequestHeaders
+	if len(allowed) == 0 {
+		allowed = defaultAllowedRequestHeaders
+	}
+
+	for name := range req.Header {
+		canonicalName := http.CanonicalHeaderKey(name)
+
+		if canonicalName == "Authorization" {
+			continue
+		}
+
+		var keep bool
+		for _, a := range allowed {
+			if canonicalName == http.CanonicalHeaderKey(a) {
+				keep = true
+				break
+			}
+		}
+
+		if !keep {
+			req.Header.Del(canonicalName)
+		}
+	}
+}
+
 // RoundTrip is called last and is used to manipulate the forwarded request using context.
 func (p *Proxy) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Here we have successfully authenticated so now need to determine whether
 	// we need use impersonation or not.
 
+	// Strip any header that isn't explicitly allowed before forwarding.
+	p.filterRequestHeaders(req)
+
 	// If no impersonation then we return here without setting impersonation
 	// header but re-introduce the token we removed.
 	if context.NoImpersonation(req) {
 		token := context.BearerToken(req)
 		req.Header.Add("Authorization", token)
-		return p.noAuthClientTransport.RoundTrip(req)
+		resp, err := p.noAuthClientTransport.RoundTrip(req)
+		p.emitAuditEvent(req, authPathNoImpersonation, nil, nil, nil, statusCodeOf(resp))
+		return resp, err
 	}
 
 	// Get the impersonation headers from the context.
 	impersonationConf := context.ImpersonationConfig(req)
 	if impersonationConf == nil {
-		return nil, errNoImpersonationConfig
+		// No OIDC impersonation context was set, e.g. because the request
+		// authenticated via a client certificate issued by our own signing
+		// CA instead of an OIDC bearer token. Build an impersonation config
+		// directly from the verified certificate rather than failing.
+		impConf, ok := p.impersonationConfigFromClientCert(req)
+		if !ok {
+			return nil, errNoImpersonationConfig
+		}
+
+		rt := transport.NewImpersonatingRoundTripper(impConf, p.clientTransport)
+		resp, err := rt.RoundTrip(req)
+		sarAllowed := p.subjectAccessReviewAllowed(req)
+		p.emitAuditEvent(req, authPathImpersonation, &user.DefaultInfo{Name: impConf.UserName, Groups: impConf.Groups}, &impConf, sarAllowed, statusCodeOf(resp))
+		return resp, err
 	}
 
 	// Set up impersonation request.
@@ -270,6 +565,79 @@ func (p *Proxy) RoundTrip(req *http.Request) (*http.Response, error) {
 	logging.LogSuccessfulRequest(req, *impersonationConf.InboundUser, *impersonationConf.ImpersonatedUser)
 
 	// Push request through round trippers to the API server.
+	resp, err := rt.RoundTrip(req)
+	sarAllowed := p.subjectAccessReviewAllowed(req)
+	p.emitAuditEvent(req, authPathImpersonation, *impersonationConf.InboundUser, impersonationConf.ImpersonationConfig, sarAllowed, statusCodeOf(resp))
+	return resp, err
+}
+
+// statusCodeOf returns resp's HTTP status code, or 0 if resp is nil (e.g.
+// the round trip errored before a response was received).
+func statusCodeOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// impersonationConfigFromClientCert builds an impersonation config from a
+// client certificate presented over mTLS and signed by the proxy's own
+// certSigner CA, recognising the caller without needing to re-run the OIDC
+// verify path on the hot path.
+func (p *Proxy) impersonationConfigFromClientCert(req *http.Request) (transport.ImpersonationConfig, bool) {
+	if p.certSigner == nil || req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return transport.ImpersonationConfig{}, false
+	}
+
+	peerCert := req.TLS.PeerCertificates[0]
+	verifyOpts, _ := p.certSigner.VerifyOptions()
+	if _, err := peerCert.Verify(verifyOpts); err != nil {
+		return transport.ImpersonationConfig{}, false
+	}
+
+	username, groups := userInfoFromClientCert(peerCert.Subject)
+	if username == "" {
+		return transport.ImpersonationConfig{}, false
+	}
+
+	return transport.ImpersonationConfig{
+		UserName: username,
+		Groups:   groups,
+	}, true
+}
+
+// certAuthRoundTripper is the Transport used for WebSocket upgrades
+// authenticated via a client certificate issued by certSigner. It mirrors
+// the client-cert branch of Proxy.RoundTrip: filter the forwarded headers,
+// build an impersonation config from the verified certificate, and
+// impersonate through to the API server, so the WebSocket path (kubectl
+// exec/attach/port-forward) is forwarded as the certificate's user rather
+// than the proxy's own identity. Auditing (open/close events with byte
+// counts) is handled by the caller via serveWebSocketWithAudit, the same
+// as for the OIDC-impersonated WebSocket path, rather than here: a single
+// event emitted from RoundTrip would cover only the handshake, not the
+// life of the connection.
+type certAuthRoundTripper struct {
+	p *Proxy
+}
+
+// WrappedRoundTripper implements k8s.io/client-go/transport.WrappedRoundTripper
+// so that UpgradeAwareHandler can unwrap down to the underlying
+// *http.Transport to dial raw upgraded connections with the right TLS
+// config, the same as it does for the non-cert-auth wsProxyHandler.
+func (c certAuthRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return c.p.clientTransport
+}
+
+func (c certAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.p.filterRequestHeaders(req)
+
+	impConf, ok := c.p.impersonationConfigFromClientCert(req)
+	if !ok {
+		return nil, errNoImpersonationConfig
+	}
+
+	rt := transport.NewImpersonatingRoundTripper(impConf, c.p.clientTransport)
 	return rt.RoundTrip(req)
 }
 
@@ -284,6 +652,7 @@ func (p *Proxy) reviewToken(rw http.ResponseWriter, req *http.Request) bool {
 	if err != nil {
 		klog.Errorf("unable to authenticate the request via TokenReview due to an error (%s): %s",
 			remoteAddr, err)
+		p.emitAuditEvent(req, authPathTokenReview, nil, nil, nil, 0)
 		return false
 	}
 
@@ -291,20 +660,73 @@ func (p *Proxy) reviewToken(rw http.ResponseWriter, req *http.Request) bool {
 		klog.V(4).Infof("passing request with valid token through (%s)",
 			remoteAddr)
 
+		p.emitAuditEvent(req, authPathTokenReview, nil, nil, nil, 0)
 		return false
 	}
 
-	// No error and ok so passthrough the request
-	return true
+	// A token validated by TokenReview still needs authorizing before it's
+	// let through without impersonation, so confirm it via a
+	// SubjectAccessReview (when one is configured) and record the
+	// decision on the audit event.
+	sarAllowed := p.subjectAccessReviewAllowed(req)
+	p.emitAuditEvent(req, authPathTokenReview, nil, nil, sarAllowed, 0)
+
+	// No SAR client configured: fall back to trusting TokenReview alone.
+	if p.subjectAccessReviewer == nil {
+		return true
+	}
+
+	// A SAR client is configured but the review errored, so the token has
+	// not been authorized and is not let through.
+	if sarAllowed == nil {
+		return false
+	}
+
+	return *sarAllowed
+}
+
+// subjectAccessReviewAllowed runs req through the configured
+// SubjectAccessReview client to authorize an authentication decision,
+// returning its result for inclusion in the audit event. Returns nil if no
+// SAR client is configured or the review itself errored, matching the
+// audit event's "SubjectAccessReview result (if any)" semantics.
+func (p *Proxy) subjectAccessReviewAllowed(req *http.Request) *bool {
+	if p.subjectAccessReviewer == nil {
+		return nil
+	}
+
+	allowed, err := p.subjectAccessReviewer.Review(req)
+	if err != nil {
+		klog.Errorf("unable to authorize request via SubjectAccessReview: %s", err)
+		return nil
+	}
+
+	return &allowed
 }
 
 func (p *Proxy) roundTripperForRestConfig(config *rest.Config) (http.RoundTripper, error) {
+	return roundTripperForRestConfig(config, p.config.TLSProfile)
+}
+
+// roundTripperForRestConfig builds the http.RoundTripper used to talk to
+// the API server identified by config: a transport honouring profile's TLS
+// restrictions, wrapped so a configured BearerTokenFile (e.g. a
+// kubelet-projected service account token) is re-read whenever it rotates
+// on disk instead of sending a stale token for the lifetime of the
+// process. It is a free function, rather than a *Proxy method, so it can
+// also back the TokenReview and SubjectAccessReview clients built by New,
+// which exist before a *Proxy does.
+func roundTripperForRestConfig(config *rest.Config, profile TLSProfile) (http.RoundTripper, error) {
 	// get golang tls config to the API server
 	tlsConfig, err := rest.TLSConfigFor(config)
 	if err != nil {
 		return nil, err
 	}
 
+	// restrict the TLS versions, cipher suites and curves used to talk to
+	// the upstream API server according to the configured profile
+	applyTLSProfile(profile, tlsConfig)
+
 	// create tls transport to request
 	tlsTransport := &http.Transport{
 		Proxy:           http.ProxyFromEnvironment,
@@ -323,9 +745,50 @@ func (p *Proxy) roundTripperForRestConfig(config *rest.Config) (http.RoundTrippe
 		return nil, err
 	}
 
+	// If a bearer token file is configured (e.g. a kubelet-projected
+	// service account token), wrap the transport so it re-reads the file
+	// whenever it rotates on disk instead of sending a stale token for
+	// the lifetime of the process.
+	clientRT = WrapTransportWithBearerTokenFile(clientRT, config.BearerTokenFile, config.BearerToken)
+
 	return clientRT, nil
 }
 
+// newTokenReviewer builds a tokenreview.TokenReview client talking to the
+// API server identified by restConfig over the same TLS-profiled,
+// BearerTokenFile-aware transport as the proxy's own upstream connection
+// (see roundTripperForRestConfig), so a rotated token keeps authenticating
+// TokenReview calls rather than going stale.
+func newTokenReviewer(restConfig *rest.Config, profile TLSProfile) (*tokenreview.TokenReview, error) {
+	rt, err := roundTripperForRestConfig(restConfig, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfigAndClient(restConfig, &http.Client{Transport: rt})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenreview.New(client.AuthenticationV1()), nil
+}
+
+// newSubjectAccessReviewer builds a subjectaccessreview.SubjectAccessReview
+// client the same way as newTokenReviewer.
+func newSubjectAccessReviewer(restConfig *rest.Config, profile TLSProfile) (*subjectaccessreview.SubjectAccessReview, error) {
+	rt, err := roundTripperForRestConfig(restConfig, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kubernetes.NewForConfigAndClient(restConfig, &http.Client{Transport: rt})
+	if err != nil {
+		return nil, err
+	}
+
+	return subjectaccessreview.New(client.AuthorizationV1()), nil
+}
+
 // Return the proxy OIDC token authenticator
 func (p *Proxy) OIDCTokenAuthenticator() authenticator.Token {
 	return p.tokenAuther
@@ -0,0 +1,118 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// TLSProfile selects the set of TLS versions, cipher suites and curves the
+// proxy will accept and offer, both when serving requests and when talking
+// to the upstream API server.
+type TLSProfile string
+
+const (
+	// TLSProfileSecure restricts the proxy to TLS 1.3 only. Go's TLS 1.3
+	// stack does not allow configuring cipher suites, so operators opting
+	// into this profile are trusting the standard library's curated set.
+	// Clients that cannot speak TLS 1.3 will be refused outright.
+	TLSProfileSecure TLSProfile = "secure"
+
+	// TLSProfileDefault allows TLS 1.2 and 1.3, restricted to a curated
+	// set of modern AEAD cipher suites and elliptic curves. This is the
+	// profile used when none is configured.
+	TLSProfileDefault TLSProfile = "default"
+
+	// TLSProfileLegacy allows TLS 1.2 and 1.3 with a broader cipher suite
+	// set, for interoperating with older upstream API servers or webhooks
+	// that don't support the default profile's restricted set.
+	TLSProfileLegacy TLSProfile = "default-ldap"
+)
+
+// defaultCipherSuites is the curated AEAD cipher suite set used by
+// TLSProfileDefault.
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+// legacyCipherSuites extends defaultCipherSuites with older suites (ECDHE
+// with CBC-mode AES, and plain RSA key exchange with AES-GCM) retained for
+// interop with legacy upstream servers.
+var legacyCipherSuites = append(append([]uint16{}, defaultCipherSuites...),
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+)
+
+// defaultCurvePreferences is used by both TLSProfileDefault and
+// TLSProfileLegacy.
+var defaultCurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+
+// ValidateTLSProfile returns an error if profile is not one of the known
+// TLSProfile values, or is empty.
+func ValidateTLSProfile(profile TLSProfile) error {
+	switch profile {
+	case TLSProfileSecure, TLSProfileDefault, TLSProfileLegacy:
+		return nil
+	default:
+		return fmt.Errorf("unrecognised TLS profile %q, must be one of %q, %q, %q",
+			profile, TLSProfileSecure, TLSProfileDefault, TLSProfileLegacy)
+	}
+}
+
+// applyTLSProfile mutates tlsConfig in place to apply profile, defaulting
+// to TLSProfileDefault when profile is empty.
+func applyTLSProfile(profile TLSProfile, tlsConfig *tls.Config) {
+	if profile == "" {
+		profile = TLSProfileDefault
+	}
+
+	switch profile {
+	case TLSProfileSecure:
+		tlsConfig.MinVersion = tls.VersionTLS13
+		tlsConfig.MaxVersion = tls.VersionTLS13
+
+	case TLSProfileLegacy:
+		tlsConfig.MinVersion = tls.VersionTLS12
+		tlsConfig.CipherSuites = legacyCipherSuites
+		tlsConfig.CurvePreferences = defaultCurvePreferences
+
+	case TLSProfileDefault:
+		fallthrough
+	default:
+		tlsConfig.MinVersion = tls.VersionTLS12
+		tlsConfig.CipherSuites = defaultCipherSuites
+		tlsConfig.CurvePreferences = defaultCurvePreferences
+	}
+}
+
+// tlsProfileCipherSuites returns the cipher suite IDs serving info should
+// advertise for profile, or nil for TLSProfileSecure where Go's TLS 1.3
+// stack does not allow configuring cipher suites.
+func tlsProfileCipherSuites(profile TLSProfile) []uint16 {
+	switch profile {
+	case TLSProfileLegacy:
+		return legacyCipherSuites
+	case TLSProfileSecure:
+		return nil
+	case TLSProfileDefault:
+		fallthrough
+	default:
+		return defaultCipherSuites
+	}
+}
+
+// tlsProfileMinVersion returns the minimum TLS version serving info should
+// enforce for profile.
+func tlsProfileMinVersion(profile TLSProfile) uint16 {
+	if profile == TLSProfileSecure {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
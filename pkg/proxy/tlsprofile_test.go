@@ -0,0 +1,121 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestValidateTLSProfile(t *testing.T) {
+	tests := map[string]struct {
+		profile TLSProfile
+		wantErr bool
+	}{
+		"secure is valid":    {profile: TLSProfileSecure},
+		"default is valid":   {profile: TLSProfileDefault},
+		"legacy is valid":    {profile: TLSProfileLegacy},
+		"empty is invalid":   {profile: "", wantErr: true},
+		"unknown is invalid": {profile: "made-up", wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateTLSProfile(test.profile)
+			if test.wantErr != (err != nil) {
+				t.Errorf("ValidateTLSProfile(%q) error = %v, wantErr = %v", test.profile, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyTLSProfile(t *testing.T) {
+	tests := map[string]struct {
+		profile        TLSProfile
+		wantMinVersion uint16
+		wantMaxVersion uint16
+		wantCiphers    []uint16
+	}{
+		"secure restricts to TLS 1.3 only": {
+			profile:        TLSProfileSecure,
+			wantMinVersion: tls.VersionTLS13,
+			wantMaxVersion: tls.VersionTLS13,
+		},
+		"legacy allows TLS 1.2 with the broader cipher suite set": {
+			profile:        TLSProfileLegacy,
+			wantMinVersion: tls.VersionTLS12,
+			wantCiphers:    legacyCipherSuites,
+		},
+		"default allows TLS 1.2 with the curated cipher suite set": {
+			profile:        TLSProfileDefault,
+			wantMinVersion: tls.VersionTLS12,
+			wantCiphers:    defaultCipherSuites,
+		},
+		"empty profile behaves like default": {
+			profile:        "",
+			wantMinVersion: tls.VersionTLS12,
+			wantCiphers:    defaultCipherSuites,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tlsConfig := &tls.Config{}
+			applyTLSProfile(test.profile, tlsConfig)
+
+			if tlsConfig.MinVersion != test.wantMinVersion {
+				t.Errorf("got MinVersion %#x, want %#x", tlsConfig.MinVersion, test.wantMinVersion)
+			}
+			if test.wantMaxVersion != 0 && tlsConfig.MaxVersion != test.wantMaxVersion {
+				t.Errorf("got MaxVersion %#x, want %#x", tlsConfig.MaxVersion, test.wantMaxVersion)
+			}
+			if test.wantCiphers != nil && !cipherSuitesEqual(tlsConfig.CipherSuites, test.wantCiphers) {
+				t.Errorf("got CipherSuites %v, want %v", tlsConfig.CipherSuites, test.wantCiphers)
+			}
+		})
+	}
+}
+
+func TestTLSProfileCipherSuites(t *testing.T) {
+	tests := map[string]struct {
+		profile TLSProfile
+		want    []uint16
+	}{
+		"secure has none, configured by the TLS 1.3 stack": {profile: TLSProfileSecure, want: nil},
+		"legacy returns the broader set":                   {profile: TLSProfileLegacy, want: legacyCipherSuites},
+		"default returns the curated set":                  {profile: TLSProfileDefault, want: defaultCipherSuites},
+		"unknown falls back to the curated set":            {profile: "made-up", want: defaultCipherSuites},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := tlsProfileCipherSuites(test.profile)
+			if !cipherSuitesEqual(got, test.want) {
+				t.Errorf("tlsProfileCipherSuites(%q) = %v, want %v", test.profile, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTLSProfileMinVersion(t *testing.T) {
+	if got := tlsProfileMinVersion(TLSProfileSecure); got != tls.VersionTLS13 {
+		t.Errorf("tlsProfileMinVersion(secure) = %#x, want TLS 1.3", got)
+	}
+	if got := tlsProfileMinVersion(TLSProfileDefault); got != tls.VersionTLS12 {
+		t.Errorf("tlsProfileMinVersion(default) = %#x, want TLS 1.2", got)
+	}
+	if got := tlsProfileMinVersion(TLSProfileLegacy); got != tls.VersionTLS12 {
+		t.Errorf("tlsProfileMinVersion(legacy) = %#x, want TLS 1.2", got)
+	}
+}
+
+func cipherSuitesEqual(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+)
+
+// swappableTokenAuthenticator implements authenticator.Token by delegating
+// to an authenticator.Token that can be atomically swapped out, so that the
+// OIDC authenticator can be rebuilt in the background (e.g. when the issuer
+// CA rotates) without requiring callers to re-fetch it.
+type swappableTokenAuthenticator struct {
+	mu     sync.RWMutex
+	auther authenticator.Token
+}
+
+func newSwappableTokenAuthenticator(auther authenticator.Token) *swappableTokenAuthenticator {
+	return &swappableTokenAuthenticator{auther: auther}
+}
+
+func (s *swappableTokenAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	s.mu.RLock()
+	auther := s.auther
+	s.mu.RUnlock()
+
+	return auther.AuthenticateToken(ctx, token)
+}
+
+// Set swaps the delegate authenticator used by subsequent calls to
+// AuthenticateToken.
+func (s *swappableTokenAuthenticator) Set(auther authenticator.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auther = auther
+}
+
+// swappableRoundTripper implements http.RoundTripper by delegating to a
+// round tripper that can be atomically swapped out, so the transport to the
+// API server can be rebuilt in the background (e.g. when the CA used to
+// verify it rotates) without callers needing to re-fetch it.
+type swappableRoundTripper struct {
+	mu sync.RWMutex
+	rt http.RoundTripper
+}
+
+func newSwappableRoundTripper(rt http.RoundTripper) *swappableRoundTripper {
+	return &swappableRoundTripper{rt: rt}
+}
+
+func (s *swappableRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.RLock()
+	rt := s.rt
+	s.mu.RUnlock()
+
+	return rt.RoundTrip(req)
+}
+
+// Set swaps the delegate round tripper used by subsequent calls to
+// RoundTrip.
+func (s *swappableRoundTripper) Set(rt http.RoundTripper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rt = rt
+}
+
+// WrappedRoundTripper implements k8s.io/client-go/transport.WrappedRoundTripper
+// so that callers which unwrap the transport chain to reach the underlying
+// *http.Transport (e.g. apimachinery's UpgradeAwareHandler, to dial raw
+// upgraded connections with the right TLS config) can see through the swap.
+func (s *swappableRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rt
+}
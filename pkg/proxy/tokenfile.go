@@ -0,0 +1,120 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	netutil "k8s.io/apimachinery/pkg/util/net"
+)
+
+// defaultBearerTokenFileRefreshInterval bounds how long a bearer token read
+// from a file is trusted before it is re-read, even if the file's mtime
+// hasn't changed (e.g. because the filesystem doesn't support mtime
+// notifications reliably, as can be the case with some projected volumes).
+const defaultBearerTokenFileRefreshInterval = time.Minute
+
+// bearerTokenFileSource reads a bearer token from a file that is refreshed
+// periodically on disk (such as a kubelet-projected service account token),
+// re-reading it whenever its mtime changes or refreshInterval has elapsed.
+// If the file is unreadable it keeps returning the last token it read
+// successfully, or the static fallback token if it has never read one.
+type bearerTokenFileSource struct {
+	file            string
+	fallback        string
+	refreshInterval time.Duration
+
+	mu       sync.Mutex
+	lastRead time.Time
+	lastMod  time.Time
+	cached   string
+}
+
+func newBearerTokenFileSource(file, fallback string, refreshInterval time.Duration) *bearerTokenFileSource {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultBearerTokenFileRefreshInterval
+	}
+
+	return &bearerTokenFileSource{
+		file:            file,
+		fallback:        fallback,
+		refreshInterval: refreshInterval,
+		cached:          fallback,
+	}
+}
+
+// Token returns the current bearer token, re-reading the backing file if
+// it has changed or enough time has elapsed since the last read.
+func (s *bearerTokenFileSource) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if info, err := os.Stat(s.file); err == nil {
+		if !info.ModTime().Equal(s.lastMod) || time.Since(s.lastRead) > s.refreshInterval {
+			if data, err := ioutil.ReadFile(s.file); err == nil {
+				s.cached = strings.TrimSpace(string(data))
+				s.lastMod = info.ModTime()
+			}
+			s.lastRead = time.Now()
+		}
+	}
+
+	return s.cached
+}
+
+// bearerTokenFileRoundTripper sets the Authorization header on every
+// request from a bearerTokenFileSource before delegating to an underlying
+// RoundTripper.
+type bearerTokenFileRoundTripper struct {
+	source *bearerTokenFileSource
+	rt     http.RoundTripper
+}
+
+// newBearerTokenFileRoundTripper wraps rt so that every request is
+// authenticated with the bearer token read from file, refreshed as file is
+// rotated on disk, using fallback until the file has been read successfully.
+func newBearerTokenFileRoundTripper(rt http.RoundTripper, file, fallback string, refreshInterval time.Duration) http.RoundTripper {
+	return &bearerTokenFileRoundTripper{
+		source: newBearerTokenFileSource(file, fallback, refreshInterval),
+		rt:     rt,
+	}
+}
+
+// WrapTransportWithBearerTokenFile wraps rt so that every request is
+// authenticated from the bearer token file, refreshed as it rotates on
+// disk, using fallback until the file has been read successfully; a no-op
+// if file is empty. Used by roundTripperForRestConfig for the proxy's own transport
+// to the API server, and, via the same function, for the TokenReview and
+// SubjectAccessReview clients built by New, so all three benefit equally
+// from a rotating service account token.
+func WrapTransportWithBearerTokenFile(rt http.RoundTripper, file, fallback string) http.RoundTripper {
+	if file == "" {
+		return rt
+	}
+	return newBearerTokenFileRoundTripper(rt, file, fallback, defaultBearerTokenFileRefreshInterval)
+}
+
+// WrappedRoundTripper implements k8s.io/client-go/transport.WrappedRoundTripper
+// so that callers which unwrap the transport chain to reach the underlying
+// *http.Transport (e.g. apimachinery's UpgradeAwareHandler, to dial raw
+// upgraded connections with the right TLS config) can see through this
+// wrapper.
+func (rt *bearerTokenFileRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+func (rt *bearerTokenFileRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := rt.source.Token()
+	if token == "" {
+		return rt.rt.RoundTrip(req)
+	}
+
+	req = netutil.CloneRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return rt.rt.RoundTrip(req)
+}
@@ -0,0 +1,68 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBearerTokenFileSourceReadsFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(file, []byte("first-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	s := newBearerTokenFileSource(file, "fallback", time.Hour)
+
+	if got, want := s.Token(), "first-token"; got != want {
+		t.Errorf("Token() = %q, want %q", got, want)
+	}
+}
+
+func TestBearerTokenFileSourcePicksUpRotation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(file, []byte("first-token"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %s", err)
+	}
+
+	s := newBearerTokenFileSource(file, "fallback", time.Hour)
+	if got, want := s.Token(), "first-token"; got != want {
+		t.Fatalf("Token() = %q, want %q", got, want)
+	}
+
+	// Force the mtime forward so the rotation is detected even if the
+	// filesystem's mtime resolution is coarser than the time between
+	// writes in this test.
+	if err := ioutil.WriteFile(file, []byte("second-token"), 0600); err != nil {
+		t.Fatalf("failed to rewrite token file: %s", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, future, future); err != nil {
+		t.Fatalf("failed to bump mtime: %s", err)
+	}
+
+	if got, want := s.Token(), "second-token"; got != want {
+		t.Errorf("Token() after rotation = %q, want %q", got, want)
+	}
+}
+
+func TestBearerTokenFileSourceFallsBackWhenFileMissing(t *testing.T) {
+	s := newBearerTokenFileSource(filepath.Join(t.TempDir(), "missing"), "fallback-token", time.Hour)
+
+	if got, want := s.Token(), "fallback-token"; got != want {
+		t.Errorf("Token() = %q, want %q", got, want)
+	}
+}
+
+func TestBearerTokenFileSourceDefaultsRefreshInterval(t *testing.T) {
+	s := newBearerTokenFileSource("", "fallback", 0)
+
+	if s.refreshInterval != defaultBearerTokenFileRefreshInterval {
+		t.Errorf("refreshInterval = %s, want %s", s.refreshInterval, defaultBearerTokenFileRefreshInterval)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright Jetstack Ltd. See LICENSE for details.
+package proxy
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// byteCountingResponseWriter wraps an http.ResponseWriter, additionally
+// implementing http.Hijacker so that upgraded WebSocket connections can
+// still be hijacked, while counting the bytes written and (once hijacked)
+// read/written over the underlying connection so they can be included in
+// the audit event emitted at connection close.
+type byteCountingResponseWriter struct {
+	http.ResponseWriter
+
+	bytesRead    int64
+	bytesWritten int64
+}
+
+func (w *byteCountingResponseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	atomic.AddInt64(&w.bytesWritten, int64(n))
+	return n, err
+}
+
+func (w *byteCountingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	counted := &byteCountingConn{Conn: conn, w: w}
+	return counted, rw, nil
+}
+
+func (w *byteCountingResponseWriter) counts() (read, written int64) {
+	return atomic.LoadInt64(&w.bytesRead), atomic.LoadInt64(&w.bytesWritten)
+}
+
+// byteCountingConn wraps a net.Conn, tallying bytes read and written into
+// the owning byteCountingResponseWriter.
+type byteCountingConn struct {
+	net.Conn
+	w *byteCountingResponseWriter
+}
+
+func (c *byteCountingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.w.bytesRead, int64(n))
+	return n, err
+}
+
+func (c *byteCountingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.w.bytesWritten, int64(n))
+	return n, err
+}